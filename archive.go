@@ -0,0 +1,291 @@
+package cpcp
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// errArchiveReadOnly is returned by every mutating Filesystem method on
+// archiveFS: tar and zip sources are read-only views over a file already
+// sitting on disk, so there's nothing sensible for Create/Mkdir/Chmod/...
+// to do.
+var errArchiveReadOnly = errors.New("tar/zip archives are read-only sources")
+
+// archiveEntry is one file, directory or symlink inside a loaded archive.
+// Regular file and symlink contents are read fully into data when the
+// archive is opened, since archive/tar and archive/zip only offer
+// streaming, in-order reads and copier needs random access (ReaderAt,
+// Readdir at arbitrary points) once walking begins.
+type archiveEntry struct {
+	name    string // full "/"-rooted path within the archive
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+	isDir   bool
+	data    []byte // file contents, or symlink target for a symlink entry
+}
+
+func (e *archiveEntry) fileInfo() os.FileInfo { return archiveFileInfo{e} }
+
+// archiveFileInfo adapts an archiveEntry to os.FileInfo.
+type archiveFileInfo struct{ e *archiveEntry }
+
+func (fi archiveFileInfo) Name() string       { return path.Base(fi.e.name) }
+func (fi archiveFileInfo) Size() int64        { return fi.e.size }
+func (fi archiveFileInfo) Mode() os.FileMode  { return fi.e.mode }
+func (fi archiveFileInfo) ModTime() time.Time { return fi.e.modTime }
+func (fi archiveFileInfo) IsDir() bool        { return fi.e.isDir }
+func (fi archiveFileInfo) Sys() interface{}   { return nil }
+
+// archiveFS implements Filesystem read-only over an in-memory index of a
+// tar or zip archive, built once up front by loadTarFS/loadZipFS. It backs
+// the "tar://" and "zip://" source schemes.
+type archiveFS struct {
+	entries map[string]*archiveEntry
+}
+
+func normalizeArchivePath(name string) string {
+	return path.Clean("/" + name)
+}
+
+func (fs *archiveFS) lookup(op, name string) (*archiveEntry, error) {
+	e, ok := fs.entries[normalizeArchivePath(name)]
+	if !ok {
+		return nil, &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+	}
+	return e, nil
+}
+
+// childrenOf returns the direct children of dir, sorted by name, for
+// Readdir.
+func (fs *archiveFS) childrenOf(dir string) []os.FileInfo {
+	dir = normalizeArchivePath(dir)
+	var out []os.FileInfo
+	for name, e := range fs.entries {
+		if name != dir && path.Dir(name) == dir {
+			out = append(out, e.fileInfo())
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name() < out[j].Name() })
+	return out
+}
+
+func (fs *archiveFS) Open(name string) (File, error) {
+	e, err := fs.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	f := &archiveFile{fs: fs, entry: e}
+	if !e.isDir && e.mode&os.ModeSymlink == 0 {
+		f.r = bytes.NewReader(e.data)
+	}
+	return f, nil
+}
+func (fs *archiveFS) Create(name string) (File, error) { return nil, errArchiveReadOnly }
+func (fs *archiveFS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE) != 0 {
+		return nil, errArchiveReadOnly
+	}
+	return fs.Open(name)
+}
+func (fs *archiveFS) Mkdir(name string, perm os.FileMode) error { return errArchiveReadOnly }
+func (fs *archiveFS) Stat(name string) (os.FileInfo, error) {
+	return fs.stat(name, 0)
+}
+
+// stat resolves symlinks before returning fileInfo, following the same
+// dereference-on-Stat convention as os.Stat. depth guards against a
+// symlink cycle within the archive.
+func (fs *archiveFS) stat(name string, depth int) (os.FileInfo, error) {
+	e, err := fs.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	if e.mode&os.ModeSymlink == 0 {
+		return e.fileInfo(), nil
+	}
+	if depth > 40 {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: errors.New("too many levels of symbolic links")}
+	}
+	target := string(e.data)
+	if !path.IsAbs(target) {
+		target = path.Join(path.Dir(e.name), target)
+	}
+	return fs.stat(target, depth+1)
+}
+func (fs *archiveFS) Lstat(name string) (os.FileInfo, error) {
+	e, err := fs.lookup("lstat", name)
+	if err != nil {
+		return nil, err
+	}
+	return e.fileInfo(), nil
+}
+func (fs *archiveFS) Readlink(name string) (string, error) {
+	e, err := fs.lookup("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	if e.mode&os.ModeSymlink == 0 {
+		return "", &os.PathError{Op: "readlink", Path: name, Err: errors.New("not a symlink")}
+	}
+	return string(e.data), nil
+}
+func (fs *archiveFS) Symlink(oldname, newname string) error             { return errArchiveReadOnly }
+func (fs *archiveFS) Chmod(name string, mode os.FileMode) error         { return errArchiveReadOnly }
+func (fs *archiveFS) Link(oldname, newname string) error                { return errArchiveReadOnly }
+func (fs *archiveFS) Rename(oldpath, newpath string) error              { return errArchiveReadOnly }
+func (fs *archiveFS) Remove(name string) error                          { return errArchiveReadOnly }
+func (fs *archiveFS) Chtimes(name string, atime, mtime time.Time) error { return errArchiveReadOnly }
+func (fs *archiveFS) Lchown(name string, uid, gid int) error            { return errArchiveReadOnly }
+
+// archiveFile is the File handle returned by archiveFS.Open. Regular files
+// read from an in-memory bytes.Reader; directories serve Readdir off a
+// lazily-computed, paginated child list.
+type archiveFile struct {
+	fs       *archiveFS
+	entry    *archiveEntry
+	r        *bytes.Reader
+	listed   bool
+	children []os.FileInfo
+	childIdx int
+}
+
+func (f *archiveFile) Read(p []byte) (int, error) {
+	if f.r == nil {
+		return 0, &os.PathError{Op: "read", Path: f.entry.name, Err: errors.New("is a directory")}
+	}
+	return f.r.Read(p)
+}
+func (f *archiveFile) ReadAt(p []byte, off int64) (int, error) {
+	if f.r == nil {
+		return 0, &os.PathError{Op: "read", Path: f.entry.name, Err: errors.New("is a directory")}
+	}
+	return f.r.ReadAt(p, off)
+}
+func (f *archiveFile) Write(p []byte) (int, error)              { return 0, errArchiveReadOnly }
+func (f *archiveFile) WriteAt(p []byte, off int64) (int, error) { return 0, errArchiveReadOnly }
+func (f *archiveFile) Close() error                             { return nil }
+
+func (f *archiveFile) Readdir(n int) ([]os.FileInfo, error) {
+	if !f.listed {
+		f.children = f.fs.childrenOf(f.entry.name)
+		f.listed = true
+	}
+	if n <= 0 {
+		rest := f.children[f.childIdx:]
+		f.childIdx = len(f.children)
+		return rest, nil
+	}
+	if f.childIdx >= len(f.children) {
+		return nil, io.EOF
+	}
+	end := f.childIdx + n
+	if end > len(f.children) {
+		end = len(f.children)
+	}
+	batch := f.children[f.childIdx:end]
+	f.childIdx = end
+	return batch, nil
+}
+
+// addSyntheticDirs backfills archiveEntry directories for every ancestor
+// path implied by an entry's name, for archives (tar in particular) that
+// don't always carry explicit directory entries.
+func addSyntheticDirs(entries map[string]*archiveEntry) {
+	for name := range entries {
+		for dir := path.Dir(name); ; dir = path.Dir(dir) {
+			if _, ok := entries[dir]; !ok {
+				entries[dir] = &archiveEntry{name: dir, mode: os.ModeDir | 0755, isDir: true}
+			}
+			if dir == "/" {
+				break
+			}
+		}
+	}
+}
+
+// loadTarFS reads every entry out of the tar archive at archivePath into
+// memory and returns a Filesystem rooted at the archive's top level.
+func loadTarFS(archivePath string) (*archiveFS, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries := map[string]*archiveEntry{"/": {name: "/", mode: os.ModeDir | 0755, isDir: true}}
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := normalizeArchivePath(strings.TrimSuffix(hdr.Name, "/"))
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			entries[name] = &archiveEntry{name: name, mode: os.FileMode(hdr.Mode) | os.ModeDir, modTime: hdr.ModTime, isDir: true}
+		case tar.TypeSymlink:
+			entries[name] = &archiveEntry{name: name, mode: os.FileMode(hdr.Mode) | os.ModeSymlink, modTime: hdr.ModTime, data: []byte(hdr.Linkname)}
+		case tar.TypeReg, tar.TypeRegA:
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			entries[name] = &archiveEntry{name: name, size: hdr.Size, mode: os.FileMode(hdr.Mode), modTime: hdr.ModTime, data: data}
+		default:
+			// Device nodes, fifos and the like have no meaningful
+			// representation once copied out; skip them. Hard link
+			// entries (tar.TypeLink) land here too and are skipped.
+		}
+	}
+	addSyntheticDirs(entries)
+	return &archiveFS{entries: entries}, nil
+}
+
+// loadZipFS reads every entry out of the zip archive at archivePath into
+// memory and returns a Filesystem rooted at the archive's top level.
+func loadZipFS(archivePath string) (*archiveFS, error) {
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	entries := map[string]*archiveEntry{"/": {name: "/", mode: os.ModeDir | 0755, isDir: true}}
+	for _, zf := range zr.File {
+		name := normalizeArchivePath(strings.TrimSuffix(zf.Name, "/"))
+		fi := zf.FileInfo()
+		if fi.IsDir() {
+			entries[name] = &archiveEntry{name: name, mode: fi.Mode(), modTime: fi.ModTime(), isDir: true}
+			continue
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		mode := fi.Mode()
+		if mode&os.ModeSymlink != 0 {
+			entries[name] = &archiveEntry{name: name, mode: mode, modTime: fi.ModTime(), data: data}
+		} else {
+			entries[name] = &archiveEntry{name: name, size: fi.Size(), mode: mode, modTime: fi.ModTime(), data: data}
+		}
+	}
+	addSyntheticDirs(entries)
+	return &archiveFS{entries: entries}, nil
+}
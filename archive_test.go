@@ -0,0 +1,211 @@
+package cpcp
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"io"
+	"os"
+	"path"
+	"testing"
+)
+
+func writeTestTar(t *testing.T, archivePath string) {
+	t.Helper()
+	f, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	tw := tar.NewWriter(f)
+	defer tw.Close()
+
+	files := []struct {
+		name string
+		body string
+	}{
+		{"top.txt", "top level"},
+		{"sub/mid.txt", "nested"},
+	}
+	for _, file := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: file.name, Mode: 0644, Size: int64(len(file.body))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(file.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: "sub/link.txt", Linkname: "mid.txt", Typeflag: tar.TypeSymlink, Mode: 0777}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestLoadTarFSWalksAndReads guards the tar backend end to end: files at
+// multiple depths are readable with their original contents, directories
+// implied by those paths (but never given their own header) still Stat as
+// directories, and a symlink entry resolves through Stat but reports its
+// own mode via Lstat.
+func TestLoadTarFSWalksAndReads(t *testing.T) {
+	dir := t.TempDir()
+	archivePath := path.Join(dir, "test.tar")
+	writeTestTar(t, archivePath)
+
+	fs, err := loadTarFS(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertArchiveFSContents(t, fs)
+}
+
+func writeTestZip(t *testing.T, archivePath string) {
+	t.Helper()
+	f, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	defer zw.Close()
+
+	files := []struct {
+		name string
+		body string
+	}{
+		{"top.txt", "top level"},
+		{"sub/mid.txt", "nested"},
+	}
+	for _, file := range files {
+		w, err := zw.Create(file.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(file.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestLoadZipFSWalksAndReads mirrors TestLoadTarFSWalksAndReads for the
+// zip backend (archive/zip has no portable cross-platform way to write a
+// symlink entry in a test fixture, so the symlink case is tar-only).
+func TestLoadZipFSWalksAndReads(t *testing.T) {
+	dir := t.TempDir()
+	archivePath := path.Join(dir, "test.zip")
+	writeTestZip(t, archivePath)
+
+	fs, err := loadZipFS(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fs.Stat("/top.txt"); err != nil {
+		t.Fatal(err)
+	}
+	assertArchiveFile(t, fs, "/top.txt", "top level")
+	assertArchiveFile(t, fs, "/sub/mid.txt", "nested")
+
+	fi, err := fs.Stat("/sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.IsDir() {
+		t.Errorf("Stat(/sub).IsDir() = false, want true (synthesized parent directory)")
+	}
+}
+
+func assertArchiveFSContents(t *testing.T, fs *archiveFS) {
+	t.Helper()
+	assertArchiveFile(t, fs, "/top.txt", "top level")
+	assertArchiveFile(t, fs, "/sub/mid.txt", "nested")
+
+	fi, err := fs.Stat("/sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.IsDir() {
+		t.Errorf("Stat(/sub).IsDir() = false, want true (synthesized parent directory)")
+	}
+
+	f, err := fs.Open("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	entries, err := f.Readdir(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := map[string]bool{}
+	for _, e := range entries {
+		names[e.Name()] = true
+	}
+	if !names["top.txt"] || !names["sub"] {
+		t.Errorf("Readdir(/) = %v, want top.txt and sub", names)
+	}
+
+	linkfi, err := fs.Lstat("/sub/link.txt")
+	if err != nil {
+		// zip fixtures don't carry a symlink entry; skip for those.
+		return
+	}
+	if linkfi.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("Lstat(/sub/link.txt).Mode() = %v, want ModeSymlink set", linkfi.Mode())
+	}
+	target, err := fs.Readlink("/sub/link.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target != "mid.txt" {
+		t.Errorf("Readlink(/sub/link.txt) = %q, want %q", target, "mid.txt")
+	}
+	// Stat (unlike Lstat) follows the link to the target's contents.
+	statfi, err := fs.Stat("/sub/link.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statfi.IsDir() || statfi.Mode()&os.ModeSymlink != 0 {
+		t.Errorf("Stat(/sub/link.txt) did not resolve through the symlink: mode %v", statfi.Mode())
+	}
+}
+
+func assertArchiveFile(t *testing.T, fs *archiveFS, p string, want string) {
+	t.Helper()
+	f, err := fs.Open(p)
+	if err != nil {
+		t.Fatalf("Open(%s): %v", p, err)
+	}
+	defer f.Close()
+	got, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatalf("read %s: %v", p, err)
+	}
+	if string(got) != want {
+		t.Errorf("contents of %s = %q, want %q", p, got, want)
+	}
+}
+
+// TestArchiveFSIsReadOnly guards the scope boundary: every mutating
+// Filesystem method on an archiveFS must fail rather than silently no-op
+// or panic, since there is no archive file to write back to.
+func TestArchiveFSIsReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	archivePath := path.Join(dir, "test.tar")
+	writeTestTar(t, archivePath)
+	fs, err := loadTarFS(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fs.Create("/new.txt"); err == nil {
+		t.Error("Create on archiveFS succeeded, want an error")
+	}
+	if err := fs.Mkdir("/newdir", 0755); err == nil {
+		t.Error("Mkdir on archiveFS succeeded, want an error")
+	}
+	if err := fs.Remove("/top.txt"); err == nil {
+		t.Error("Remove on archiveFS succeeded, want an error")
+	}
+	if err := fs.Chmod("/top.txt", 0600); err == nil {
+		t.Error("Chmod on archiveFS succeeded, want an error")
+	}
+}
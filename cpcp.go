@@ -1,16 +1,20 @@
 package cpcp
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"syscall"
+	"time"
 )
 
 func CPCP(args []string) error {
@@ -28,6 +32,19 @@ func CPCP(args []string) error {
 	syscall.Umask(u)
 	cfg.umask = os.FileMode(u & 0x1ff)
 
+	if cfg.dedup {
+		cfg.casDir = path.Join(dst, ".cpcp-cas")
+		if err := cfg.dstFS.Mkdir(cfg.casDir, 0755); err != nil && !os.IsExist(err) {
+			return fmt.Errorf("%s: %s", cfg.casDir, err)
+		}
+	}
+
+	var ttyDone chan struct{}
+	if cfg.progressMode == "tty" {
+		ttyDone = make(chan struct{})
+		go runTTYProgress(cfg, ttyDone)
+	}
+
 	msgs := make(chan string, cfg.messageBuffer)
 	msgsDone := make(chan struct{})
 	go func() {
@@ -65,32 +82,76 @@ func CPCP(args []string) error {
 		go copier(cfg, msgs, errs, wg, copyTasks, freeCopyTasks)
 	}
 
-	if len(srcs) == 1 {
+	if len(srcs) == 1 && cfg.glob && hasGlobMeta(srcs[0]) {
+		pattern := srcs[0]
+		matches, err := expandGlob(cfg.srcFS, pattern)
+		if err != nil {
+			errs <- fmtErr(pattern, err)
+		} else if len(matches) == 0 {
+			errs <- fmt.Sprintf("%s: no matches", pattern)
+		} else {
+			prefix := staticGlobPrefix(pattern)
+			for _, m := range matches {
+				rel := strings.TrimPrefix(strings.TrimPrefix(m, prefix), "/")
+				var srcfi os.FileInfo
+				if cfg.dereference {
+					srcfi, err = cfg.srcFS.Stat(m)
+				} else {
+					srcfi, err = cfg.srcFS.Lstat(m)
+				}
+				if err != nil {
+					errs <- fmtErr(m, err)
+					continue
+				}
+				if srcfi.IsDir() && !cfg.recursive {
+					errs <- fmt.Sprintf("omitting directory %q", m)
+					continue
+				}
+				mdst := path.Join(dst, rel)
+				if !srcfi.IsDir() {
+					if err := ensureDir(cfg.dstFS, path.Dir(mdst), cfg.umask); err != nil {
+						errs <- fmtErr(mdst, err)
+						continue
+					}
+				}
+				cfg.progress.discover(srcfi)
+				ct := <-freeCopyTasks
+				ct.src = m
+				ct.dst = mdst
+				ct.srcfi = srcfi
+				ct.dir = nil
+				wg.Add(1)
+				copyTasks <- ct
+			}
+		}
+	} else if len(srcs) == 1 {
 		src := srcs[0]
-		dstfi, err := os.Stat(dst)
+		dstfi, err := cfg.dstFS.Stat(dst)
 		if err == nil && dstfi.IsDir() {
 			dst = path.Join(dst, path.Base(src))
 		}
 		var srcfi os.FileInfo
 		if cfg.dereference {
-			srcfi, err = os.Stat(src)
+			srcfi, err = cfg.srcFS.Stat(src)
 		} else {
-			srcfi, err = os.Lstat(src)
+			srcfi, err = cfg.srcFS.Lstat(src)
 		}
 		if err != nil {
 			errs <- fmtErr(src, err)
 		} else if srcfi.IsDir() && !cfg.recursive {
 			errs <- fmt.Sprintf("omitting directory %q", src)
 		} else {
+			cfg.progress.discover(srcfi)
 			ct := <-freeCopyTasks
 			ct.src = src
 			ct.dst = dst
 			ct.srcfi = srcfi
+			ct.dir = nil
 			wg.Add(1)
 			copyTasks <- ct
 		}
 	} else {
-		dstfi, err := os.Stat(dst)
+		dstfi, err := cfg.dstFS.Stat(dst)
 		if err != nil && !os.IsNotExist(err) {
 			errs <- fmtErr(dst, err)
 		} else if os.IsNotExist(err) || !dstfi.IsDir() {
@@ -99,9 +160,9 @@ func CPCP(args []string) error {
 			for _, src := range srcs {
 				var srcfi os.FileInfo
 				if cfg.dereference {
-					srcfi, err = os.Stat(src)
+					srcfi, err = cfg.srcFS.Stat(src)
 				} else {
-					srcfi, err = os.Lstat(src)
+					srcfi, err = cfg.srcFS.Lstat(src)
 				}
 				if err != nil {
 					errs <- fmtErr(src, err)
@@ -111,10 +172,12 @@ func CPCP(args []string) error {
 					errs <- fmt.Sprintf("omitting directory %q", src)
 					continue
 				}
+				cfg.progress.discover(srcfi)
 				ct := <-freeCopyTasks
 				ct.src = src
 				ct.dst = path.Join(dst, path.Base(src))
 				ct.srcfi = srcfi
+				ct.dir = nil
 				wg.Add(1)
 				copyTasks <- ct
 			}
@@ -123,6 +186,10 @@ func CPCP(args []string) error {
 
 	wg.Wait()
 
+	if ttyDone != nil {
+		close(ttyDone)
+	}
+
 	close(msgs)
 	<-msgsDone
 	close(errs)
@@ -145,10 +212,35 @@ type config struct {
 	parallelTasks int
 	readdirBuffer int
 	copyBuffer    int
+	blockSync     bool
+	blockSize     int64
+	srcFS         Filesystem
+	dstFS         Filesystem
+	dedup         bool
+	casDir        string
+	casDigests    map[string]*casEntry
+	casMu         sync.Mutex
+	glob          bool
+
+	preserveTimestamps bool
+	preserveOwnership  bool
+	preserveXattr      bool
+	linkedInodes       map[devIno]*linkEntry
+	linkMu             sync.Mutex
+
+	progressMode string
+	progress     *Progress
 
 	umask os.FileMode
 }
 
+// devIno identifies a file by (device, inode), used to detect hardlinks
+// under preserve=links.
+type devIno struct {
+	dev uint64
+	ino uint64
+}
+
 func parseArgs(args []string) (*config, []string, string, error) {
 	cfg := &config{
 		verbosity:     0,
@@ -161,6 +253,11 @@ func parseArgs(args []string) (*config, []string, string, error) {
 		parallelTasks: 1000,
 		readdirBuffer: 1000,
 		copyBuffer:    65536,
+		blockSize:     128 * 1024,
+		casDigests:    make(map[string]*casEntry),
+		linkedInodes:  make(map[devIno]*linkEntry),
+		progressMode:  "plain",
+		progress:      &Progress{},
 	}
 	setPreserve := func(arg string) error {
 		preserves := strings.Split(arg, ",")
@@ -171,9 +268,18 @@ func parseArgs(args []string) (*config, []string, string, error) {
 				cfg.preserveLinks = true
 			case "mode":
 				cfg.preserveMode = true
+			case "timestamps":
+				cfg.preserveTimestamps = true
+			case "ownership":
+				cfg.preserveOwnership = true
+			case "xattr":
+				cfg.preserveXattr = true
 			case "all":
 				cfg.preserveLinks = true
 				cfg.preserveMode = true
+				cfg.preserveTimestamps = true
+				cfg.preserveOwnership = true
+				cfg.preserveXattr = true
 			default:
 				return fmt.Errorf("unsupported preserve specification %q\n", preserve)
 			}
@@ -181,6 +287,7 @@ func parseArgs(args []string) (*config, []string, string, error) {
 		return nil
 	}
 	var srcs []string
+	var globSet bool
 	for i := 0; i < len(args); i++ {
 		if args[i] == "" || args[i][0] != '-' {
 			srcs = append(srcs, args[i])
@@ -248,6 +355,29 @@ func parseArgs(args []string) (*config, []string, string, error) {
 				cfg.recursive = true
 			case "verbose":
 				cfg.verbosity++
+			case "update-blocks":
+				cfg.blockSync = true
+			case "block-size":
+				sz, err := strconv.ParseInt(arg, 10, 64)
+				if err != nil || sz <= 0 {
+					return nil, nil, "", fmt.Errorf("invalid block-size %q", arg)
+				}
+				cfg.blockSize = sz
+			case "dedup":
+				cfg.dedup = true
+			case "glob":
+				cfg.glob = true
+				globSet = true
+			case "no-glob":
+				cfg.glob = false
+				globSet = true
+			case "progress":
+				switch arg {
+				case "plain", "json", "tty":
+					cfg.progressMode = arg
+				default:
+					return nil, nil, "", fmt.Errorf("unsupported progress mode %q", arg)
+				}
 			}
 		}
 	}
@@ -257,13 +387,67 @@ func parseArgs(args []string) (*config, []string, string, error) {
 	case 1:
 		return nil, nil, "", fmt.Errorf("missing destination parameter after %q", srcs[0])
 	}
-	return cfg, srcs[:len(srcs)-1], srcs[len(srcs)-1], nil
+	rawSrcs := srcs[:len(srcs)-1]
+	rawDst := srcs[len(srcs)-1]
+
+	if !globSet && len(rawSrcs) == 1 && hasGlobMeta(rawSrcs[0]) {
+		cfg.glob = true
+	}
+
+	dstFS, dst, err := filesystemForScheme(rawDst)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	cfg.dstFS = dstFS
+
+	plainSrcs := make([]string, len(rawSrcs))
+	for i, rawSrc := range rawSrcs {
+		srcFS, plainSrc, err := filesystemForScheme(rawSrc)
+		if err != nil {
+			return nil, nil, "", err
+		}
+		if cfg.srcFS == nil {
+			cfg.srcFS = srcFS
+		}
+		plainSrcs[i] = plainSrc
+	}
+
+	return cfg, plainSrcs, dst, nil
 }
 
 type copyTask struct {
 	src   string
 	dst   string
 	srcfi os.FileInfo
+	dir   *dirState
+}
+
+// dirState tracks how many of a directory's children haven't finished
+// copying yet (starting at 1, for "still enumerating children"). Once it
+// reaches zero, the directory's own preserve=timestamps is (re)applied and
+// the same accounting happens on its parent, so an ancestor's mtime is
+// only ever touched after its whole subtree has been copied in.
+type dirState struct {
+	dst     string
+	srcfi   os.FileInfo
+	parent  *dirState
+	pending int64
+}
+
+// finishChild records that one of d's children has finished (or, when d
+// itself was just done enumerating its children, that there are no more
+// to add) and, once d has no pending children left, applies its deferred
+// timestamps and propagates the same completion up to d.parent.
+func finishChild(cfg *config, errs chan string, d *dirState) {
+	for d != nil {
+		if atomic.AddInt64(&d.pending, -1) != 0 {
+			return
+		}
+		if err := applyTimestamps(cfg, d.dst, d.srcfi); err != nil {
+			errs <- fmtErr(d.dst, err)
+		}
+		d = d.parent
+	}
 }
 
 func fmtErr(pth string, err error) string {
@@ -288,6 +472,7 @@ func copier(cfg *config, msgs chan string, errs chan string, wg *sync.WaitGroup,
 		var src string
 		var dst string
 		var srcfi os.FileInfo
+		var dir *dirState
 		if i := len(localTasks); i > 0 {
 			i--
 			ct := localTasks[i]
@@ -297,21 +482,27 @@ func copier(cfg *config, msgs chan string, errs chan string, wg *sync.WaitGroup,
 				fct.src = ct.src
 				fct.dst = ct.dst
 				fct.srcfi = ct.srcfi
+				fct.dir = ct.dir
 				copyTasks <- fct
 				continue
 			default:
 				src = ct.src
 				dst = ct.dst
 				srcfi = ct.srcfi
+				dir = ct.dir
 			}
 		} else {
 			ct := <-copyTasks
 			src = ct.src
 			dst = ct.dst
 			srcfi = ct.srcfi
+			dir = ct.dir
 			freeCopyTasks <- ct
 		}
-		if cfg.verbosity > 0 {
+		// Regular files log their "src -> dst" line via reportCopy once the
+		// copy actually finishes; directories and symlinks have no
+		// reportCopy equivalent, so log them here instead.
+		if cfg.verbosity > 0 && cfg.progressMode == "plain" && !srcfi.Mode().IsRegular() {
 			msgs <- fmt.Sprintf("%s -> %s", src, dst)
 		}
 		if srcfi.IsDir() {
@@ -323,21 +514,30 @@ func copier(cfg *config, msgs chan string, errs chan string, wg *sync.WaitGroup,
 			if !cfg.preserveMode {
 				m &= cfg.umask
 			}
-			if err := os.Mkdir(dst, m); err != nil {
+			if err := cfg.dstFS.Mkdir(dst, m); err != nil {
 				if !os.IsExist(err) {
 					errs <- fmtErr(dst, err)
 				}
 			}
+			if err := applyPreserve(cfg, src, dst, srcfi); err != nil {
+				errs <- fmtErr(dst, err)
+			}
 			// The above Mkdir doesn't always seem to apply the exact mode we
 			// asked it to.
 			if cfg.preserveMode {
-				if err := os.Chmod(dst, m); err != nil {
+				if err := cfg.dstFS.Chmod(dst, m); err != nil {
 					errs <- fmtErr(dst, err)
 				}
 			}
-			f, err := os.Open(src)
+			// ds defers this directory's own preserve=timestamps until its
+			// subtree has been fully copied in (see finishChild); applying
+			// it here, before any children exist, would just get bumped
+			// back out by the first child write.
+			ds := &dirState{dst: dst, srcfi: srcfi, parent: dir, pending: 1}
+			f, err := cfg.srcFS.Open(src)
 			if err != nil {
 				errs <- fmtErr(src, err)
+				finishChild(cfg, errs, ds)
 				wg.Done()
 				continue
 			}
@@ -346,18 +546,22 @@ func copier(cfg *config, msgs chan string, errs chan string, wg *sync.WaitGroup,
 				for _, fi := range fis {
 					subsrc := path.Join(src, fi.Name())
 					subdst := path.Join(dst, fi.Name())
+					cfg.progress.discover(fi)
+					atomic.AddInt64(&ds.pending, 1)
 					wg.Add(1)
 					select {
 					case ct := <-freeCopyTasks:
 						ct.src = subsrc
 						ct.dst = subdst
 						ct.srcfi = fi
+						ct.dir = ds
 						copyTasks <- ct
 					default:
 						localTasks = append(localTasks, &copyTask{
 							src:   subsrc,
 							dst:   subdst,
 							srcfi: fi,
+							dir:   ds,
 						})
 					}
 				}
@@ -369,38 +573,276 @@ func copier(cfg *config, msgs chan string, errs chan string, wg *sync.WaitGroup,
 					break
 				}
 			}
+			finishChild(cfg, errs, ds)
 		} else if srcfi.Mode().IsRegular() {
-			srcf, err := os.Open(src)
-			if err != nil {
-				errs <- fmtErr(src, err)
-			} else {
-				dstf, err := os.Create(dst)
+			linked, markDone, err := linkIfSeen(cfg, srcfi, dst)
+			if linked {
+				if cfg.verbosity > 0 && cfg.progressMode == "plain" {
+					msgs <- fmt.Sprintf("%s -> %s", src, dst)
+				}
 				if err != nil {
 					errs <- fmtErr(dst, err)
-				} else {
-					_, err := io.CopyBuffer(dstf, srcf, copyBuf)
-					if err != nil {
-						errs <- fmtErr(dst, err)
-					}
-					srcf.Close()
-					dstf.Close()
-					m := srcfi.Mode()
-					if !cfg.preserveMode {
-						m &= cfg.umask
-					}
-					if err := os.Chmod(dst, m); err != nil {
-						errs <- fmtErr(dst, err)
-					}
 				}
+				finishChild(cfg, errs, dir)
+				wg.Done()
+				continue
+			}
+			cfg.progress.fileStarted()
+			copyStart := time.Now()
+			var copyErr error
+			if cfg.dedup {
+				copyErr = dedupCopy(cfg, src, dst)
+			} else if cfg.blockSync {
+				copyErr = blockCopy(cfg, src, dst, srcfi)
+			} else {
+				copyErr = fullCopy(cfg, src, dst, copyBuf)
 			}
+			cfg.progress.fileFinished()
+			reportCopy(cfg, msgs, src, dst, srcfi.Size(), time.Since(copyStart), copyErr)
+			if copyErr != nil {
+				errs <- fmtErr(dst, copyErr)
+			} else {
+				// Ownership (and xattrs) are applied before mode: chown(2)
+				// silently clears setuid/setgid bits, so doing this after
+				// Chmod would drop any suid/sgid bit we just set.
+				if err := applyPreserve(cfg, src, dst, srcfi); err != nil {
+					errs <- fmtErr(dst, err)
+				}
+				m := srcfi.Mode()
+				if !cfg.preserveMode {
+					m &= cfg.umask
+				}
+				if err := cfg.dstFS.Chmod(dst, m); err != nil {
+					errs <- fmtErr(dst, err)
+				}
+				if err := applyTimestamps(cfg, dst, srcfi); err != nil {
+					errs <- fmtErr(dst, err)
+				}
+			}
+			if markDone != nil {
+				markDone(copyErr)
+			}
+			finishChild(cfg, errs, dir)
 		} else if srcfi.Mode()|os.ModeSymlink != 0 {
-			target, err := os.Readlink(src)
+			target, err := cfg.srcFS.Readlink(src)
 			if err != nil {
 				errs <- fmtErr(src, err)
-			} else if err = os.Symlink(target, dst); err != nil {
+			} else if err = cfg.dstFS.Symlink(target, dst); err != nil {
 				errs <- fmtErr(dst, err)
+			} else {
+				if err := applyPreserve(cfg, src, dst, srcfi); err != nil {
+					errs <- fmtErr(dst, err)
+				}
+				if err := applyTimestamps(cfg, dst, srcfi); err != nil {
+					errs <- fmtErr(dst, err)
+				}
 			}
+			finishChild(cfg, errs, dir)
 		}
 		wg.Done()
 	}
 }
+
+// fullCopy copies src to dst in full, overwriting whatever was at dst.
+func fullCopy(cfg *config, src string, dst string, copyBuf []byte) error {
+	srcf, err := cfg.srcFS.Open(src)
+	if err != nil {
+		return err
+	}
+	defer srcf.Close()
+	dstf, err := cfg.dstFS.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer dstf.Close()
+	_, err = io.CopyBuffer(&progressWriter{w: dstf, p: cfg.progress}, srcf, copyBuf)
+	return err
+}
+
+// fileBlock is the hash of a single fixed-size region of a file, mirroring
+// the {Offset, Size, Hash} tuples used by Syncthing's block exchange
+// protocol.
+type fileBlock struct {
+	offset int64
+	size   int64
+	hash   [sha256.Size]byte
+}
+
+// hashFileBlocks splits f (of the given size) into cfg.blockSize chunks and
+// hashes each one with SHA-256, fanning the work out across worker
+// goroutines bounded by runtime.NumCPU.
+func hashFileBlocks(f File, size int64, blockSize int64) ([]fileBlock, error) {
+	n := (size + blockSize - 1) / blockSize
+	blocks := make([]fileBlock, n)
+	for i := range blocks {
+		off := int64(i) * blockSize
+		sz := blockSize
+		if off+sz > size {
+			sz = size - off
+		}
+		blocks[i].offset = off
+		blocks[i].size = sz
+	}
+	wg := &sync.WaitGroup{}
+	sem := make(chan struct{}, runtime.NumCPU())
+	var errMu sync.Mutex
+	var firstErr error
+	for i := range blocks {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(b *fileBlock) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			buf := make([]byte, b.size)
+			if _, err := f.ReadAt(buf, b.offset); err != nil && err != io.EOF {
+				errMu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				errMu.Unlock()
+				return
+			}
+			b.hash = sha256.Sum256(buf)
+		}(&blocks[i])
+	}
+	wg.Wait()
+	return blocks, firstErr
+}
+
+// blockCopy implements --update-blocks: when dst already exists with the
+// same size as src, only the blocks whose hashes differ are rewritten,
+// instead of recopying the whole file. It falls back to a plain fullCopy
+// when the sizes disagree or dst does not exist yet.
+func blockCopy(cfg *config, src string, dst string, srcfi os.FileInfo) error {
+	dstfi, err := cfg.dstFS.Stat(dst)
+	if err != nil || dstfi.IsDir() || dstfi.Size() != srcfi.Size() {
+		return fullCopy(cfg, src, dst, make([]byte, cfg.copyBuffer))
+	}
+	srcf, err := cfg.srcFS.Open(src)
+	if err != nil {
+		return err
+	}
+	defer srcf.Close()
+	dstf, err := cfg.dstFS.OpenFile(dst, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer dstf.Close()
+	srcBlocks, err := hashFileBlocks(srcf, srcfi.Size(), cfg.blockSize)
+	if err != nil {
+		return err
+	}
+	dstBlocks, err := hashFileBlocks(dstf, dstfi.Size(), cfg.blockSize)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, cfg.blockSize)
+	for i, sb := range srcBlocks {
+		if sb.hash == dstBlocks[i].hash {
+			continue
+		}
+		b := buf[:sb.size]
+		if _, err := srcf.ReadAt(b, sb.offset); err != nil && err != io.EOF {
+			return err
+		}
+		if _, err := dstf.WriteAt(b, sb.offset); err != nil {
+			return err
+		}
+		cfg.progress.addBytes(int64(len(b)))
+	}
+	return nil
+}
+
+var casTmpSeq uint64
+
+// casEntry tracks one content digest's materialization into cfg.casDir.
+// done is closed once the digest's CAS file is in place (or failed to be),
+// so a second worker that hashes the same content while the first is still
+// renaming its temp file into place waits for that rename instead of
+// racing Link against a casPath that doesn't exist yet.
+type casEntry struct {
+	path string
+	done chan struct{}
+	err  error
+}
+
+// dedupCopy implements --dedup: src is hashed with SHA-256 first, without
+// touching the destination. If that digest has already been materialized
+// under cfg.casDir earlier in this run, dst is linked straight to it and no
+// bytes are written; CAS files left by an earlier run are not looked up and
+// get rewritten. Otherwise src is streamed into a temp
+// file under cfg.casDir, which is then renamed to a content-addressed path
+// (".cpcp-cas/<digest>") before dst is linked to it, so identical files
+// anywhere in the tree end up sharing one copy on the destination.
+func dedupCopy(cfg *config, src string, dst string) error {
+	digest, err := hashSrc(cfg, src)
+	if err != nil {
+		return err
+	}
+	casPath := path.Join(cfg.casDir, digest)
+
+	cfg.casMu.Lock()
+	entry, materializing := cfg.casDigests[digest]
+	if !materializing {
+		entry = &casEntry{path: casPath, done: make(chan struct{})}
+		cfg.casDigests[digest] = entry
+	}
+	cfg.casMu.Unlock()
+
+	if materializing {
+		<-entry.done
+	} else {
+		entry.err = materializeCAS(cfg, src, casPath)
+		close(entry.done)
+	}
+	if entry.err != nil {
+		return entry.err
+	}
+
+	if err := cfg.dstFS.Remove(dst); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return cfg.dstFS.Link(casPath, dst)
+}
+
+// hashSrc computes the SHA-256 digest of src without writing anything to
+// the destination, so files whose content has already been materialized
+// skip the temp-file write entirely.
+func hashSrc(cfg *config, src string) (string, error) {
+	srcf, err := cfg.srcFS.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer srcf.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, srcf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// materializeCAS streams src into a temp file under cfg.casDir and renames
+// it into place at casPath. Called at most once per digest per run.
+func materializeCAS(cfg *config, src string, casPath string) error {
+	srcf, err := cfg.srcFS.Open(src)
+	if err != nil {
+		return err
+	}
+	defer srcf.Close()
+
+	tmpName := path.Join(cfg.casDir, fmt.Sprintf(".tmp-%d-%d", os.Getpid(), atomic.AddUint64(&casTmpSeq, 1)))
+	tmpf, err := cfg.dstFS.Create(tmpName)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(&progressWriter{w: tmpf, p: cfg.progress}, srcf); err != nil {
+		tmpf.Close()
+		cfg.dstFS.Remove(tmpName)
+		return err
+	}
+	if err := tmpf.Close(); err != nil {
+		cfg.dstFS.Remove(tmpName)
+		return err
+	}
+	return cfg.dstFS.Rename(tmpName, casPath)
+}
@@ -0,0 +1,103 @@
+package cpcp
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"testing"
+)
+
+// TestBlockCopyOnlyRewritesChangedBlocks guards the --update-blocks
+// optimization: when dst already exists at the same size as src, only the
+// blocks whose hashes differ should be rewritten (and counted toward
+// progress), not the whole file.
+func TestBlockCopyOnlyRewritesChangedBlocks(t *testing.T) {
+	root := t.TempDir()
+	const blockSize = 8
+	const numBlocks = 4
+
+	orig := bytes.Repeat([]byte("A"), blockSize*numBlocks)
+	srcData := append([]byte(nil), orig...)
+	// Change only the third block.
+	copy(srcData[2*blockSize:3*blockSize], bytes.Repeat([]byte("B"), blockSize))
+
+	src := path.Join(root, "src")
+	dst := path.Join(root, "dst")
+	if err := os.WriteFile(src, srcData, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dst, orig, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config{
+		srcFS:      localFS{},
+		dstFS:      localFS{},
+		blockSync:  true,
+		blockSize:  blockSize,
+		copyBuffer: 65536,
+		progress:   &Progress{},
+	}
+	srcfi, err := os.Lstat(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := blockCopy(cfg, src, dst, srcfi); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, srcData) {
+		t.Errorf("dst content = %q, want %q", got, srcData)
+	}
+
+	bytesCopied, _, _, _, _ := cfg.progress.snapshot()
+	if bytesCopied != blockSize {
+		t.Errorf("progress counted %d bytes rewritten, want %d (exactly the one changed block)", bytesCopied, blockSize)
+	}
+}
+
+// TestBlockCopyFallsBackWhenSizeDiffers guards the "sizes disagree" escape
+// hatch: blockCopy must do a plain full copy rather than diffing blocks
+// against a dst of a different size.
+func TestBlockCopyFallsBackWhenSizeDiffers(t *testing.T) {
+	root := t.TempDir()
+	src := path.Join(root, "src")
+	dst := path.Join(root, "dst")
+	srcData := []byte("a longer replacement file")
+	if err := os.WriteFile(src, srcData, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dst, []byte("short"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config{
+		srcFS:      localFS{},
+		dstFS:      localFS{},
+		blockSync:  true,
+		blockSize:  8,
+		copyBuffer: 65536,
+		progress:   &Progress{},
+	}
+	srcfi, err := os.Lstat(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := blockCopy(cfg, src, dst, srcfi); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, srcData) {
+		t.Errorf("dst content = %q, want %q", got, srcData)
+	}
+}
@@ -0,0 +1,73 @@
+package cpcp
+
+import (
+	"os"
+	"path"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// TestDedupCopyConcurrentIdenticalContent exercises the race the CAS map is
+// meant to serialize: many goroutines dedupCopy-ing files with identical
+// content concurrently. Before casEntry gained a completion signal, a
+// worker that found materialized=true could Link against a casPath its
+// sibling hadn't renamed into place yet and fail with ENOENT.
+func TestDedupCopyConcurrentIdenticalContent(t *testing.T) {
+	dir := t.TempDir()
+	casDir := path.Join(dir, ".cpcp-cas")
+	if err := os.Mkdir(casDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	const content = "identical payload shared by every source file\n"
+	const n = 32
+	srcs := make([]string, n)
+	dsts := make([]string, n)
+	for i := 0; i < n; i++ {
+		srcs[i] = path.Join(dir, "src-"+strconv.Itoa(i))
+		dsts[i] = path.Join(dir, "dst-"+strconv.Itoa(i))
+		if err := os.WriteFile(srcs[i], []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &config{
+		srcFS:      localFS{},
+		dstFS:      localFS{},
+		dedup:      true,
+		casDir:     casDir,
+		casDigests: make(map[string]*casEntry),
+		progress:   &Progress{},
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = dedupCopy(cfg, srcs[i], dsts[i])
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("dedupCopy(%d): %v", i, err)
+		}
+	}
+	for i, dst := range dsts {
+		got, err := os.ReadFile(dst)
+		if err != nil {
+			t.Fatalf("reading %s: %v", dst, err)
+		}
+		if string(got) != content {
+			t.Errorf("dst %d content = %q, want %q", i, got, content)
+		}
+	}
+
+	if len(cfg.casDigests) != 1 {
+		t.Errorf("expected exactly one CAS digest for identical content, got %d", len(cfg.casDigests))
+	}
+}
@@ -0,0 +1,48 @@
+package cpcp
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+// TestDirectoryTimestampsAppliedAfterSubtree guards against preserve=timestamps
+// being defeated on directories: applying it right after Mkdir, before
+// children are copied in, means every child write just bumps mtime back
+// out. It must be (re)applied only once the whole subtree is copied.
+func TestDirectoryTimestampsAppliedAfterSubtree(t *testing.T) {
+	root := t.TempDir()
+	src := path.Join(root, "src")
+	dst := path.Join(root, "dst")
+	if err := os.Mkdir(src, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(dst, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		name := path.Join(src, string(rune('a'+i)))
+		if err := os.WriteFile(name, []byte("contents"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	old := time.Unix(1000000, 0)
+	if err := os.Chtimes(src, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CPCP([]string{"--recursive", "--preserve=timestamps", src, dst}); err != nil {
+		t.Fatal(err)
+	}
+
+	copiedDir := path.Join(dst, "src")
+	fi, err := os.Lstat(copiedDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.ModTime().Equal(old) {
+		t.Errorf("copied directory mtime = %v, want %v (children writes should not have bumped it)", fi.ModTime(), old)
+	}
+}
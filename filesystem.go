@@ -0,0 +1,113 @@
+package cpcp
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// File is the subset of *os.File that a Filesystem implementation's handles
+// must support. The local backend's handles satisfy it directly; other
+// backends wrap their own handle types to match.
+type File interface {
+	io.Reader
+	io.Writer
+	io.ReaderAt
+	io.WriterAt
+	io.Closer
+	Readdir(n int) ([]os.FileInfo, error)
+}
+
+// Filesystem abstracts the handful of os-level operations copier and CPCP
+// need, so that a source or destination tree could in principle live
+// somewhere other than local disk (an SFTP server, an S3 bucket, a
+// read-only tar/zip archive, ...). This build ships localFS plus the
+// read-only archiveFS backing "tar://" and "zip://" sources; sftp/s3 need
+// a network client this build doesn't vendor, so they're recognized in
+// filesystemForScheme but left unimplemented. One Filesystem is resolved
+// per source/destination URL scheme in parseArgs and held on config as
+// cfg.srcFS / cfg.dstFS.
+type Filesystem interface {
+	Open(name string) (File, error)
+	Create(name string) (File, error)
+	OpenFile(name string, flag int, perm os.FileMode) (File, error)
+	Mkdir(name string, perm os.FileMode) error
+	Stat(name string) (os.FileInfo, error)
+	Lstat(name string) (os.FileInfo, error)
+	Readlink(name string) (string, error)
+	Symlink(oldname, newname string) error
+	Chmod(name string, mode os.FileMode) error
+	Link(oldname, newname string) error
+	Rename(oldpath, newpath string) error
+	Remove(name string) error
+	Chtimes(name string, atime, mtime time.Time) error
+	Lchown(name string, uid, gid int) error
+}
+
+// localFS implements Filesystem directly on top of the os package and is
+// the default backend used for any source or destination with no URL
+// scheme.
+type localFS struct{}
+
+func (localFS) Open(name string) (File, error)   { return os.Open(name) }
+func (localFS) Create(name string) (File, error) { return os.Create(name) }
+func (localFS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	return os.OpenFile(name, flag, perm)
+}
+func (localFS) Mkdir(name string, perm os.FileMode) error { return os.Mkdir(name, perm) }
+func (localFS) Stat(name string) (os.FileInfo, error)     { return os.Stat(name) }
+func (localFS) Lstat(name string) (os.FileInfo, error)    { return os.Lstat(name) }
+func (localFS) Readlink(name string) (string, error)      { return os.Readlink(name) }
+func (localFS) Symlink(oldname, newname string) error     { return os.Symlink(oldname, newname) }
+func (localFS) Chmod(name string, mode os.FileMode) error { return os.Chmod(name, mode) }
+func (localFS) Link(oldname, newname string) error        { return os.Link(oldname, newname) }
+func (localFS) Rename(oldpath, newpath string) error      { return os.Rename(oldpath, newpath) }
+func (localFS) Remove(name string) error                  { return os.Remove(name) }
+func (localFS) Chtimes(name string, atime, mtime time.Time) error {
+	return os.Chtimes(name, atime, mtime)
+}
+func (localFS) Lchown(name string, uid, gid int) error { return os.Lchown(name, uid, gid) }
+
+// splitScheme pulls a "scheme://" prefix off of a source or destination
+// argument, e.g. "s3://bucket/prefix" -> ("s3", "bucket/prefix"). Arguments
+// without a scheme (the common, local-path case) return "".
+func splitScheme(raw string) (scheme string, rest string) {
+	i := strings.Index(raw, "://")
+	if i < 0 {
+		return "", raw
+	}
+	return raw[:i], raw[i+len("://"):]
+}
+
+// filesystemForScheme resolves the Filesystem backend and bare path for a
+// single source or destination argument. Local paths resolve to localFS;
+// "tar://" and "zip://" resolve to a read-only archiveFS loaded from the
+// archive file at the scheme's path, rooted at "/" inside the archive.
+// sftp/s3 need a network client this build doesn't vendor, so they're
+// recognized here (for a precise "not supported" error) but not
+// implemented.
+func filesystemForScheme(raw string) (Filesystem, string, error) {
+	scheme, rest := splitScheme(raw)
+	switch scheme {
+	case "":
+		return localFS{}, raw, nil
+	case "tar":
+		fs, err := loadTarFS(rest)
+		if err != nil {
+			return nil, "", fmt.Errorf("%s://%s: %w", scheme, rest, err)
+		}
+		return fs, "/", nil
+	case "zip":
+		fs, err := loadZipFS(rest)
+		if err != nil {
+			return nil, "", fmt.Errorf("%s://%s: %w", scheme, rest, err)
+		}
+		return fs, "/", nil
+	case "sftp", "s3":
+		return nil, "", fmt.Errorf("%s://%s: %s backend is not supported in this build", scheme, rest, scheme)
+	default:
+		return nil, "", fmt.Errorf("unrecognized scheme %q", scheme)
+	}
+}
@@ -0,0 +1,47 @@
+package cpcp
+
+import "testing"
+
+// TestFilesystemForSchemeUnsupportedBackends guards the scope boundary:
+// remote schemes are recognized (so a typo-free "s3://..." argument gets a
+// precise error) but deliberately unimplemented in this build (no network
+// client vendored), rather than silently falling through to treating the
+// scheme as part of a local path.
+func TestFilesystemForSchemeUnsupportedBackends(t *testing.T) {
+	for _, scheme := range []string{"sftp", "s3"} {
+		fs, rest, err := filesystemForScheme(scheme + "://bucket/prefix")
+		if fs != nil || rest != "" || err == nil {
+			t.Errorf("filesystemForScheme(%q): got (%v, %q, %v), want an error", scheme, fs, rest, err)
+		}
+	}
+}
+
+// TestFilesystemForSchemeArchiveMissingFile guards the tar/zip error path:
+// a scheme path that doesn't exist on disk should surface that failure
+// rather than silently resolving to an empty archive.
+func TestFilesystemForSchemeArchiveMissingFile(t *testing.T) {
+	for _, scheme := range []string{"tar", "zip"} {
+		if _, _, err := filesystemForScheme(scheme + ":///no/such/archive"); err == nil {
+			t.Errorf("filesystemForScheme(%q missing file) = nil error, want an error", scheme)
+		}
+	}
+}
+
+func TestFilesystemForSchemeLocal(t *testing.T) {
+	fs, rest, err := filesystemForScheme("/some/local/path")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fs.(localFS); !ok {
+		t.Errorf("filesystemForScheme(local path) returned %T, want localFS", fs)
+	}
+	if rest != "/some/local/path" {
+		t.Errorf("rest = %q, want unchanged path", rest)
+	}
+}
+
+func TestFilesystemForSchemeUnrecognized(t *testing.T) {
+	if _, _, err := filesystemForScheme("ftp://host/path"); err == nil {
+		t.Error("filesystemForScheme(unrecognized scheme) = nil error, want an error")
+	}
+}
@@ -0,0 +1,179 @@
+package cpcp
+
+import (
+	"os"
+	"path"
+	"strings"
+)
+
+// hasGlobMeta reports whether s contains any unquoted glob metacharacter
+// ('*', '?', '[').
+func hasGlobMeta(s string) bool {
+	return strings.ContainsAny(s, "*?[")
+}
+
+// staticGlobPrefix returns the leading, metacharacter-free directory
+// portion of a glob pattern, e.g. "/var/log/**/*.gz" -> "/var/log". Matches
+// found under the pattern have this prefix stripped to get the relative
+// path that gets recreated under dst.
+func staticGlobPrefix(pattern string) string {
+	segs := strings.Split(pattern, "/")
+	var out []string
+	for _, seg := range segs {
+		if seg == "**" || hasGlobMeta(seg) {
+			break
+		}
+		out = append(out, seg)
+	}
+	return strings.Join(out, "/")
+}
+
+// expandGlob resolves a doublestar-style pattern ("**" crossing directory
+// boundaries, plus the usual "*", "?" and "[...]" within a single segment)
+// against fs into the flat list of matching paths. Inspired by buildkit's
+// ChecksumWildcard path handling.
+func expandGlob(fs Filesystem, pattern string) ([]string, error) {
+	base := "."
+	rest := pattern
+	if strings.HasPrefix(pattern, "/") {
+		base = "/"
+		rest = pattern[1:]
+	}
+	var segs []string
+	for _, seg := range strings.Split(rest, "/") {
+		if seg != "" {
+			segs = append(segs, seg)
+		}
+	}
+	return matchGlobSegments(fs, base, segs)
+}
+
+func matchGlobSegments(fs Filesystem, base string, segs []string) ([]string, error) {
+	if len(segs) == 0 {
+		if _, err := fs.Lstat(base); err != nil {
+			return nil, nil
+		}
+		return []string{base}, nil
+	}
+	seg, rest := segs[0], segs[1:]
+	if seg == "**" {
+		if len(rest) == 0 {
+			// A trailing "**" matches files at every depth under base,
+			// doublestar-style -- not just the directories crossed to
+			// reach them. Returning the directories themselves here
+			// would make the caller recursively re-copy the same
+			// subtree once per directory depth, so only leaf files are
+			// returned; their parent directories get recreated by
+			// ensureDir at copy time.
+			return walkGlobFiles(fs, base)
+		}
+		matches, err := matchGlobSegments(fs, base, rest)
+		if err != nil {
+			return nil, err
+		}
+		entries, err := readGlobDir(fs, base)
+		if err != nil {
+			return matches, nil
+		}
+		for _, fi := range entries {
+			if !fi.IsDir() {
+				continue
+			}
+			sub, err := matchGlobSegments(fs, path.Join(base, fi.Name()), segs)
+			if err != nil {
+				return nil, err
+			}
+			matches = append(matches, sub...)
+		}
+		return matches, nil
+	}
+	if !hasGlobMeta(seg) {
+		return matchGlobSegments(fs, path.Join(base, seg), rest)
+	}
+	entries, err := readGlobDir(fs, base)
+	if err != nil {
+		return nil, nil
+	}
+	var matches []string
+	for _, fi := range entries {
+		ok, err := path.Match(seg, fi.Name())
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		sub, err := matchGlobSegments(fs, path.Join(base, fi.Name()), rest)
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, sub...)
+	}
+	return matches, nil
+}
+
+// walkGlobFiles recursively collects every non-directory entry under base,
+// for a trailing "**" pattern segment.
+func walkGlobFiles(fs Filesystem, base string) ([]string, error) {
+	entries, err := readGlobDir(fs, base)
+	if err != nil {
+		return nil, nil
+	}
+	var matches []string
+	for _, fi := range entries {
+		full := path.Join(base, fi.Name())
+		if fi.IsDir() {
+			sub, err := walkGlobFiles(fs, full)
+			if err != nil {
+				return nil, err
+			}
+			matches = append(matches, sub...)
+			continue
+		}
+		matches = append(matches, full)
+	}
+	return matches, nil
+}
+
+// ensureDir creates dir and any missing parents, mirroring os.MkdirAll but
+// going through a Filesystem so glob expansion can recreate the relative
+// directory structure of a "**" match under dst.
+func ensureDir(fs Filesystem, dir string, perm os.FileMode) error {
+	if dir == "" || dir == "." || dir == "/" {
+		return nil
+	}
+	if fi, err := fs.Stat(dir); err == nil {
+		if fi.IsDir() {
+			return nil
+		}
+		return &os.PathError{Op: "mkdir", Path: dir, Err: os.ErrExist}
+	}
+	if err := ensureDir(fs, path.Dir(dir), perm); err != nil {
+		return err
+	}
+	if err := fs.Mkdir(dir, perm); err != nil && !os.IsExist(err) {
+		return err
+	}
+	return nil
+}
+
+func readGlobDir(fs Filesystem, dir string) ([]os.FileInfo, error) {
+	fi, err := fs.Stat(dir)
+	if err != nil || !fi.IsDir() {
+		return nil, os.ErrInvalid
+	}
+	f, err := fs.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries []os.FileInfo
+	for {
+		fis, err := f.Readdir(1000)
+		entries = append(entries, fis...)
+		if err != nil {
+			break
+		}
+	}
+	return entries, nil
+}
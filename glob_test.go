@@ -0,0 +1,143 @@
+package cpcp
+
+import (
+	"os"
+	"path"
+	"sort"
+	"testing"
+)
+
+func mkTree(t *testing.T, root string, files []string) {
+	t.Helper()
+	for _, f := range files {
+		full := path.Join(root, f)
+		if err := os.MkdirAll(path.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestHasGlobMeta(t *testing.T) {
+	cases := map[string]bool{
+		"/var/log/app.log": false,
+		"/var/log/*.log":   true,
+		"file?.txt":        true,
+		"set[abc]":         true,
+	}
+	for s, want := range cases {
+		if got := hasGlobMeta(s); got != want {
+			t.Errorf("hasGlobMeta(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
+
+func TestStaticGlobPrefix(t *testing.T) {
+	cases := map[string]string{
+		"/var/log/*.gz":    "/var/log",
+		"/var/log/**/*.gz": "/var/log",
+		"/a/b/c":           "/a/b/c",
+		"*.go":             "",
+	}
+	for pattern, want := range cases {
+		if got := staticGlobPrefix(pattern); got != want {
+			t.Errorf("staticGlobPrefix(%q) = %q, want %q", pattern, got, want)
+		}
+	}
+}
+
+func TestExpandGlobSingleSegment(t *testing.T) {
+	root := t.TempDir()
+	mkTree(t, root, []string{"a.log", "b.log", "c.txt"})
+
+	matches, err := expandGlob(localFS{}, path.Join(root, "*.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(matches)
+	want := []string{path.Join(root, "a.log"), path.Join(root, "b.log")}
+	if len(matches) != len(want) || matches[0] != want[0] || matches[1] != want[1] {
+		t.Errorf("expandGlob(*.log) = %v, want %v", matches, want)
+	}
+}
+
+// TestExpandGlobDoublestar guards the "**" directory-crossing case: it
+// should match files at every depth under the prefix, not just the
+// immediate directory.
+func TestExpandGlobDoublestar(t *testing.T) {
+	root := t.TempDir()
+	mkTree(t, root, []string{
+		"top.gz",
+		"sub/mid.gz",
+		"sub/deeper/bottom.gz",
+		"sub/deeper/skip.txt",
+	})
+
+	matches, err := expandGlob(localFS{}, path.Join(root, "**/*.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(matches)
+	want := []string{
+		path.Join(root, "sub/deeper/bottom.gz"),
+		path.Join(root, "sub/mid.gz"),
+		path.Join(root, "top.gz"),
+	}
+	sort.Strings(want)
+	if len(matches) != len(want) {
+		t.Fatalf("expandGlob(**/*.gz) = %v, want %v", matches, want)
+	}
+	for i := range want {
+		if matches[i] != want[i] {
+			t.Errorf("expandGlob(**/*.gz)[%d] = %q, want %q", i, matches[i], want[i])
+		}
+	}
+}
+
+// TestExpandGlobTrailingDoublestar guards a bare trailing "**": it should
+// match files at every depth under the prefix (not just the directory
+// skeleton), and each file should appear exactly once, not once per
+// directory level crossed to reach it.
+func TestExpandGlobTrailingDoublestar(t *testing.T) {
+	root := t.TempDir()
+	mkTree(t, root, []string{
+		"top.txt",
+		"sub/mid.txt",
+		"sub/deeper/bottom.txt",
+	})
+
+	matches, err := expandGlob(localFS{}, path.Join(root, "**"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(matches)
+	want := []string{
+		path.Join(root, "sub/deeper/bottom.txt"),
+		path.Join(root, "sub/mid.txt"),
+		path.Join(root, "top.txt"),
+	}
+	sort.Strings(want)
+	if len(matches) != len(want) {
+		t.Fatalf("expandGlob(**) = %v, want %v", matches, want)
+	}
+	for i := range want {
+		if matches[i] != want[i] {
+			t.Errorf("expandGlob(**)[%d] = %q, want %q", i, matches[i], want[i])
+		}
+	}
+}
+
+func TestExpandGlobNoMatches(t *testing.T) {
+	root := t.TempDir()
+	mkTree(t, root, []string{"a.txt"})
+
+	matches, err := expandGlob(localFS{}, path.Join(root, "*.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 0 {
+		t.Errorf("expandGlob(no matches) = %v, want empty", matches)
+	}
+}
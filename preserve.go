@@ -0,0 +1,143 @@
+package cpcp
+
+import "os"
+
+// linkEntry tracks one (dev, inode) group's first destination path. done
+// is closed once that first copy has actually finished, so a concurrent
+// copy of another hardlink to the same inode waits for it instead of
+// racing Link against a destination that hasn't been written yet.
+type linkEntry struct {
+	dst  string
+	done chan struct{}
+	err  error
+}
+
+// linkIfSeen implements the preserve=links half of cp -a: if srcfi's
+// (dev, inode) pair has already been seen earlier in this run, dst is
+// hardlinked to that earlier destination instead of being copied again.
+// The bool return reports whether dst was handled here (linked or failed
+// to link); when false the caller must copy srcfi to dst itself and call
+// the returned markDone once that copy finishes, so that concurrent copies
+// of the same inode can wait for it before linking. markDone is nil when
+// preserve=links is off or srcfi's (dev, inode) pair is unavailable.
+func linkIfSeen(cfg *config, srcfi os.FileInfo, dst string) (handled bool, markDone func(error), err error) {
+	if !cfg.preserveLinks {
+		return false, nil, nil
+	}
+	dev, ino, ok := fileDevIno(srcfi)
+	if !ok {
+		return false, nil, nil
+	}
+	key := devIno{dev, ino}
+	cfg.linkMu.Lock()
+	entry, seen := cfg.linkedInodes[key]
+	if !seen {
+		entry = &linkEntry{dst: dst, done: make(chan struct{})}
+		cfg.linkedInodes[key] = entry
+	}
+	cfg.linkMu.Unlock()
+	if !seen {
+		return false, func(copyErr error) {
+			entry.err = copyErr
+			close(entry.done)
+		}, nil
+	}
+	<-entry.done
+	if entry.err != nil {
+		return true, nil, entry.err
+	}
+	if err := cfg.dstFS.Remove(dst); err != nil && !os.IsNotExist(err) {
+		return true, nil, err
+	}
+	return true, nil, cfg.dstFS.Link(entry.dst, dst)
+}
+
+// applyPreserve applies whichever of preserve=ownership and preserve=xattr
+// are enabled to an already-copied dst, pulling the source values from
+// srcfi (and, for xattrs, src itself). Callers that also preserve=mode must
+// run this before chmod'ing dst: chown(2) silently clears setuid/setgid
+// bits, so applying ownership after mode would drop them right back out.
+// preserve=timestamps is handled separately by applyTimestamps.
+//
+// When srcfi is a symlink, both ownership and xattrs are applied to the
+// link itself (Lchown already does this; xattrs use the L*XATTR syscalls
+// below) rather than to whatever it points at, which may not even exist.
+func applyPreserve(cfg *config, src string, dst string, srcfi os.FileInfo) error {
+	if cfg.preserveOwnership {
+		if uid, gid, ok := fileOwner(srcfi); ok {
+			if err := cfg.dstFS.Lchown(dst, uid, gid); err != nil {
+				return err
+			}
+		}
+	}
+	if cfg.preserveXattr {
+		var err error
+		if srcfi.Mode()&os.ModeSymlink != 0 {
+			err = copyXattrL(src, dst)
+		} else {
+			err = copyXattr(src, dst)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyTimestamps applies preserve=timestamps to an already-copied dst,
+// pulling the source atime/mtime from srcfi. Split out from applyPreserve
+// so directory copies can defer it until their subtree has been copied in
+// (see the dirState handling in copier), rather than right after Mkdir
+// where every child write would just bump mtime back out.
+//
+// For a symlink srcfi, this sets the link's own timestamps via lutimes
+// rather than following it with Chtimes, which would stamp the target (or
+// fail outright if the link is dangling).
+func applyTimestamps(cfg *config, dst string, srcfi os.FileInfo) error {
+	if !cfg.preserveTimestamps {
+		return nil
+	}
+	atime, mtime := fileTimes(srcfi)
+	if srcfi.Mode()&os.ModeSymlink != 0 {
+		return lutimes(dst, atime, mtime)
+	}
+	return cfg.dstFS.Chtimes(dst, atime, mtime)
+}
+
+// copyXattr copies every extended attribute from src to dst.
+func copyXattr(src string, dst string) error {
+	names, err := xattrList(src)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		value, err := xattrGet(src, name)
+		if err != nil {
+			return err
+		}
+		if err := xattrSet(dst, name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyXattrL is copyXattr for symlinks: it reads and writes the extended
+// attributes of the links themselves via the L*XATTR syscalls, rather than
+// following src/dst to their targets.
+func copyXattrL(src string, dst string) error {
+	names, err := xattrListL(src)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		value, err := xattrGetL(src, name)
+		if err != nil {
+			return err
+		}
+		if err := xattrSetL(dst, name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+//go:build linux
+
+package cpcp
+
+import (
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// fileTimes pulls the real atime/mtime out of the platform-specific Stat_t,
+// since os.FileInfo only exposes mtime portably.
+func fileTimes(fi os.FileInfo) (atime, mtime time.Time) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fi.ModTime(), fi.ModTime()
+	}
+	return time.Unix(st.Atim.Sec, st.Atim.Nsec), time.Unix(st.Mtim.Sec, st.Mtim.Nsec)
+}
+
+// fileDevIno returns the (device, inode) pair identifying fi's underlying
+// file, used to detect and recreate hardlinks under preserve=links.
+func fileDevIno(fi os.FileInfo) (dev uint64, ino uint64, ok bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return st.Dev, st.Ino, true
+}
+
+// fileOwner returns the uid/gid recorded in fi's Stat_t.
+func fileOwner(fi os.FileInfo) (uid int, gid int, ok bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return int(st.Uid), int(st.Gid), true
+}
+
+const xattrListBufSize = 4096
+
+// xattrList lists the extended attribute names set on path.
+func xattrList(path string) ([]string, error) {
+	buf := make([]byte, xattrListBufSize)
+	for {
+		n, _, errno := syscall.Syscall(syscall.SYS_LISTXATTR, uintptr(unsafe.Pointer(strPtr(path))), uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)))
+		if errno == syscall.ERANGE {
+			buf = make([]byte, len(buf)*2)
+			continue
+		}
+		if errno != 0 {
+			return nil, errno
+		}
+		return splitNulTerminated(buf[:n]), nil
+	}
+}
+
+// xattrGet reads the value of a single extended attribute.
+func xattrGet(path string, name string) ([]byte, error) {
+	buf := make([]byte, xattrListBufSize)
+	for {
+		n, _, errno := syscall.Syscall6(syscall.SYS_GETXATTR, uintptr(unsafe.Pointer(strPtr(path))), uintptr(unsafe.Pointer(strPtr(name))), uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)), 0, 0)
+		if errno == syscall.ERANGE {
+			buf = make([]byte, len(buf)*2)
+			continue
+		}
+		if errno != 0 {
+			return nil, errno
+		}
+		return buf[:n], nil
+	}
+}
+
+// xattrSet writes a single extended attribute on path.
+func xattrSet(path string, name string, value []byte) error {
+	var valuePtr unsafe.Pointer
+	if len(value) > 0 {
+		valuePtr = unsafe.Pointer(&value[0])
+	}
+	_, _, errno := syscall.Syscall6(syscall.SYS_SETXATTR, uintptr(unsafe.Pointer(strPtr(path))), uintptr(unsafe.Pointer(strPtr(name))), uintptr(valuePtr), uintptr(len(value)), 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// xattrListL, xattrGetL and xattrSetL mirror xattrList, xattrGet and
+// xattrSet but act on a symlink itself rather than whatever it points to,
+// via the L*XATTR syscalls. Used when preserve=xattr reaches a symlink, so
+// a dangling or mischievous target doesn't get xattrs read from or written
+// to instead of the link.
+func xattrListL(path string) ([]string, error) {
+	buf := make([]byte, xattrListBufSize)
+	for {
+		n, _, errno := syscall.Syscall(syscall.SYS_LLISTXATTR, uintptr(unsafe.Pointer(strPtr(path))), uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)))
+		if errno == syscall.ERANGE {
+			buf = make([]byte, len(buf)*2)
+			continue
+		}
+		if errno != 0 {
+			return nil, errno
+		}
+		return splitNulTerminated(buf[:n]), nil
+	}
+}
+
+func xattrGetL(path string, name string) ([]byte, error) {
+	buf := make([]byte, xattrListBufSize)
+	for {
+		n, _, errno := syscall.Syscall6(syscall.SYS_LGETXATTR, uintptr(unsafe.Pointer(strPtr(path))), uintptr(unsafe.Pointer(strPtr(name))), uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)), 0, 0)
+		if errno == syscall.ERANGE {
+			buf = make([]byte, len(buf)*2)
+			continue
+		}
+		if errno != 0 {
+			return nil, errno
+		}
+		return buf[:n], nil
+	}
+}
+
+func xattrSetL(path string, name string, value []byte) error {
+	var valuePtr unsafe.Pointer
+	if len(value) > 0 {
+		valuePtr = unsafe.Pointer(&value[0])
+	}
+	_, _, errno := syscall.Syscall6(syscall.SYS_LSETXATTR, uintptr(unsafe.Pointer(strPtr(path))), uintptr(unsafe.Pointer(strPtr(name))), uintptr(valuePtr), uintptr(len(value)), 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// atFDCWD and atSymlinkNoFollow are AT_FDCWD and AT_SYMLINK_NOFOLLOW from
+// linux/fcntl.h. The syscall package doesn't export either (they're used
+// internally as unexported constants), so we carry our own copies here
+// to drive utimensat(2) directly, matching this file's existing
+// raw-syscall approach to xattrs.
+const (
+	atFDCWD           = -0x64
+	atSymlinkNoFollow = 0x100
+)
+
+// lutimes sets the atime/mtime of the symlink at path itself, rather than
+// its target: Go's syscall package exposes no portable lutimes(3)
+// equivalent, so this calls utimensat(2) with AT_SYMLINK_NOFOLLOW directly.
+func lutimes(path string, atime, mtime time.Time) error {
+	ts := [2]syscall.Timespec{
+		syscall.NsecToTimespec(atime.UnixNano()),
+		syscall.NsecToTimespec(mtime.UnixNano()),
+	}
+	dirfd := atFDCWD
+	_, _, errno := syscall.Syscall6(syscall.SYS_UTIMENSAT, uintptr(dirfd), uintptr(unsafe.Pointer(strPtr(path))), uintptr(unsafe.Pointer(&ts[0])), uintptr(atSymlinkNoFollow), 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func strPtr(s string) *byte {
+	b := append([]byte(s), 0)
+	return &b[0]
+}
+
+func splitNulTerminated(b []byte) []string {
+	var names []string
+	start := 0
+	for i, c := range b {
+		if c == 0 {
+			if i > start {
+				names = append(names, string(b[start:i]))
+			}
+			start = i + 1
+		}
+	}
+	return names
+}
@@ -0,0 +1,155 @@
+//go:build linux
+
+package cpcp
+
+import (
+	"os"
+	"path"
+	"sync"
+	"syscall"
+	"testing"
+)
+
+// TestCopyXattrRoundTrip exercises the raw-syscall xattr path end to end:
+// set a couple of extended attributes on src, copy them with copyXattr, and
+// check dst ends up with the same names and values. Skips if the test
+// filesystem doesn't support user xattrs (e.g. some overlay/9p mounts).
+func TestCopyXattrRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	src := path.Join(dir, "src")
+	dst := path.Join(dir, "dst")
+	if err := os.WriteFile(src, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dst, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	attrs := map[string]string{
+		"user.cpcp.a": "one",
+		"user.cpcp.b": "two, a bit longer this time",
+	}
+	for name, value := range attrs {
+		if err := xattrSet(src, name, []byte(value)); err != nil {
+			if err == syscall.ENOTSUP || err == syscall.EOPNOTSUPP {
+				t.Skipf("xattrs not supported on %s: %v", dir, err)
+			}
+			t.Fatal(err)
+		}
+	}
+
+	if err := copyXattr(src, dst); err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := xattrList(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != len(attrs) {
+		t.Fatalf("xattrList(dst) = %v, want %d entries", names, len(attrs))
+	}
+	for _, name := range names {
+		want, ok := attrs[name]
+		if !ok {
+			t.Errorf("unexpected xattr %q on dst", name)
+			continue
+		}
+		got, err := xattrGet(dst, name)
+		if err != nil {
+			t.Errorf("xattrGet(dst, %q): %v", name, err)
+			continue
+		}
+		if string(got) != want {
+			t.Errorf("xattr %q = %q, want %q", name, got, want)
+		}
+	}
+}
+
+// TestArchiveCopyDanglingSymlink guards against -a (preserve=all) following
+// a symlink to apply xattr/timestamp preservation: a dangling symlink has
+// no target to follow, so applyPreserve/applyTimestamps must act on the
+// link itself (via the L*XATTR syscalls and lutimes) rather than erroring
+// out trying to stat or chtimes a path that doesn't exist.
+func TestArchiveCopyDanglingSymlink(t *testing.T) {
+	root := t.TempDir()
+	src := path.Join(root, "src")
+	dst := path.Join(root, "dst")
+	if err := os.Mkdir(src, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(path.Join(src, "does-not-exist"), path.Join(src, "dangling")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CPCP([]string{"-a", src, dst}); err != nil {
+		t.Fatalf("CPCP -a with dangling symlink: %v", err)
+	}
+
+	fi, err := os.Lstat(path.Join(dst, "dangling"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("dst/dangling is not a symlink: %v", fi.Mode())
+	}
+}
+
+// TestLinkIfSeenConcurrentHardlinks exercises the race linkEntry's done
+// channel is meant to serialize: several goroutines racing to copy
+// different names of the same source inode under preserve=links. Before
+// the completion signal, a worker that saw seen=true could Link against a
+// dst its sibling hadn't written yet and fail with ENOENT.
+func TestLinkIfSeenConcurrentHardlinks(t *testing.T) {
+	dir := t.TempDir()
+	srcPath := path.Join(dir, "src")
+	if err := os.WriteFile(srcPath, []byte("hardlinked content\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	srcfi, err := os.Lstat(srcPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config{
+		dstFS:         localFS{},
+		preserveLinks: true,
+		linkedInodes:  make(map[devIno]*linkEntry),
+	}
+
+	const n = 16
+	dsts := make([]string, n)
+	for i := range dsts {
+		dsts[i] = path.Join(dir, "dst-"+string(rune('a'+i)))
+	}
+
+	var wg sync.WaitGroup
+	results := make([]error, n)
+	for i, dst := range dsts {
+		wg.Add(1)
+		go func(i int, dst string) {
+			defer wg.Done()
+			handled, markDone, err := linkIfSeen(cfg, srcfi, dst)
+			if handled {
+				results[i] = err
+				return
+			}
+			// Simulate the real copy taking a moment, to widen the
+			// window a buggy implementation would race in.
+			results[i] = os.WriteFile(dst, []byte("hardlinked content\n"), 0644)
+			markDone(results[i])
+		}(i, dst)
+	}
+	wg.Wait()
+
+	for i, err := range results {
+		if err != nil {
+			t.Errorf("dst %d: %v", i, err)
+		}
+	}
+	for _, dst := range dsts {
+		if _, err := os.Stat(dst); err != nil {
+			t.Errorf("stat %s: %v", dst, err)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package cpcp
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// orderTrackingFS wraps localFS, recording the order Chmod and Lchown are
+// called in so preserve ordering can be asserted without depending on
+// kernel setuid-clearing semantics (which differ for root vs non-root).
+type orderTrackingFS struct {
+	localFS
+	calls *[]string
+}
+
+func (fs orderTrackingFS) Chmod(name string, mode os.FileMode) error {
+	*fs.calls = append(*fs.calls, "chmod")
+	return fs.localFS.Chmod(name, mode)
+}
+
+func (fs orderTrackingFS) Lchown(name string, uid, gid int) error {
+	*fs.calls = append(*fs.calls, "lchown")
+	return fs.localFS.Lchown(name, uid, gid)
+}
+
+// TestApplyPreserveBeforeChmod checks that applyPreserve applies ownership
+// via Lchown and issues no Chmod of its own, so a caller that runs it and
+// then Chmods gets ownership before mode (chown(2) clears setuid/setgid
+// bits that a preceding Chmod would have just set). It makes the two calls
+// itself and does not exercise copier's ordering.
+func TestApplyPreserveBeforeChmod(t *testing.T) {
+	dir := t.TempDir()
+	dst := path.Join(dir, "dst")
+	if err := os.WriteFile(dst, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	srcfi, err := os.Lstat(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var calls []string
+	cfg := &config{
+		dstFS:             orderTrackingFS{calls: &calls},
+		preserveOwnership: true,
+	}
+
+	if err := applyPreserve(cfg, dst, dst, srcfi); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.dstFS.Chmod(dst, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"lchown", "chmod"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("call order = %v, want %v", calls, want)
+	}
+}
+
+// TestApplyTimestampsSplitFromApplyPreserve checks applyTimestamps and
+// applyPreserve no longer overlap: applyPreserve must not touch mtime so
+// callers are free to defer applyTimestamps (directories defer it until
+// their subtree is fully copied in).
+func TestApplyTimestampsSplitFromApplyPreserve(t *testing.T) {
+	dir := t.TempDir()
+	dst := path.Join(dir, "dst")
+	if err := os.WriteFile(dst, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Unix(1000000, 0)
+	if err := os.Chtimes(dst, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config{dstFS: localFS{}, preserveOwnership: true}
+	srcfi, err := os.Lstat(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := applyPreserve(cfg, dst, dst, srcfi); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Lstat(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.ModTime().Equal(old) {
+		t.Errorf("applyPreserve touched mtime: got %v, want unchanged %v", fi.ModTime(), old)
+	}
+}
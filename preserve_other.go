@@ -0,0 +1,42 @@
+//go:build !linux
+
+package cpcp
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// fileTimes falls back to mtime-for-both on platforms where we don't have
+// a Stat_t layout wired up; still better than leaving the copy at "now".
+func fileTimes(fi os.FileInfo) (atime, mtime time.Time) {
+	return fi.ModTime(), fi.ModTime()
+}
+
+// fileDevIno reports ok=false outside linux, so preserve=links silently
+// falls back to copying each hardlinked file independently.
+func fileDevIno(fi os.FileInfo) (dev uint64, ino uint64, ok bool) {
+	return 0, 0, false
+}
+
+func fileOwner(fi os.FileInfo) (uid int, gid int, ok bool) {
+	return 0, 0, false
+}
+
+var errXattrUnsupported = errors.New("extended attributes are not supported on this platform")
+
+func xattrList(path string) ([]string, error)               { return nil, nil }
+func xattrGet(path string, name string) ([]byte, error)     { return nil, errXattrUnsupported }
+func xattrSet(path string, name string, value []byte) error { return errXattrUnsupported }
+
+func xattrListL(path string) ([]string, error)               { return nil, nil }
+func xattrGetL(path string, name string) ([]byte, error)     { return nil, errXattrUnsupported }
+func xattrSetL(path string, name string, value []byte) error { return errXattrUnsupported }
+
+// lutimes is a no-op outside linux: there's no portable lutimes(3)
+// equivalent in Go's stdlib, so preserve=timestamps silently skips
+// symlinks here rather than setting the wrong (target's) timestamps.
+func lutimes(path string, atime, mtime time.Time) error {
+	return nil
+}
@@ -0,0 +1,167 @@
+package cpcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// Progress aggregates bytes copied, files completed, files in flight and
+// total bytes discovered so far, updated atomically from copier as work
+// happens. It backs all three --progress modes.
+type Progress struct {
+	BytesCopied    int64
+	FilesCompleted int64
+	FilesInFlight  int64
+	TotalBytes     int64
+	TotalFiles     int64
+}
+
+// discover records a file found while walking/expanding sources, before it
+// has actually been copied, so totals (and therefore ETA) account for it.
+func (p *Progress) discover(fi os.FileInfo) {
+	if !fi.Mode().IsRegular() {
+		return
+	}
+	atomic.AddInt64(&p.TotalBytes, fi.Size())
+	atomic.AddInt64(&p.TotalFiles, 1)
+}
+
+func (p *Progress) fileStarted() { atomic.AddInt64(&p.FilesInFlight, 1) }
+
+func (p *Progress) fileFinished() {
+	atomic.AddInt64(&p.FilesInFlight, -1)
+	atomic.AddInt64(&p.FilesCompleted, 1)
+}
+
+func (p *Progress) addBytes(n int64) { atomic.AddInt64(&p.BytesCopied, n) }
+
+func (p *Progress) snapshot() (bytesCopied, filesCompleted, filesInFlight, totalBytes, totalFiles int64) {
+	return atomic.LoadInt64(&p.BytesCopied),
+		atomic.LoadInt64(&p.FilesCompleted),
+		atomic.LoadInt64(&p.FilesInFlight),
+		atomic.LoadInt64(&p.TotalBytes),
+		atomic.LoadInt64(&p.TotalFiles)
+}
+
+// progressWriter wraps an io.Writer, counting every byte written through it
+// into a Progress so --progress=json|tty can report live throughput.
+type progressWriter struct {
+	w io.Writer
+	p *Progress
+}
+
+func (pw *progressWriter) Write(b []byte) (int, error) {
+	n, err := pw.w.Write(b)
+	pw.p.addBytes(int64(n))
+	return n, err
+}
+
+type progressEvent struct {
+	Src   string  `json:"src"`
+	Dst   string  `json:"dst"`
+	Bytes int64   `json:"bytes"`
+	Ms    int64   `json:"ms"`
+	MBps  float64 `json:"mbps"`
+	Error string  `json:"error,omitempty"`
+}
+
+// reportCopy emits a per-file progress message for a just-finished regular
+// file copy, according to cfg.progressMode. tty mode has nothing to do
+// here; its status line is driven by runTTYProgress instead.
+func reportCopy(cfg *config, msgs chan string, src string, dst string, bytes int64, elapsed time.Duration, copyErr error) {
+	switch cfg.progressMode {
+	case "json":
+		ev := progressEvent{Src: src, Dst: dst, Bytes: bytes, Ms: elapsed.Milliseconds(), MBps: mbps(bytes, elapsed)}
+		if copyErr != nil {
+			ev.Error = copyErr.Error()
+		}
+		if b, err := json.Marshal(ev); err == nil {
+			msgs <- string(b)
+		}
+	case "tty":
+	default:
+		if cfg.verbosity > 0 {
+			msgs <- fmt.Sprintf("%s -> %s", src, dst)
+		}
+	}
+}
+
+func mbps(bytes int64, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(bytes) / elapsed.Seconds() / (1 << 20)
+}
+
+// rollingWindow is how far back runTTYProgress looks when computing
+// throughput: long enough to smooth over a bursty tick or two, short
+// enough that the ETA still reacts to a real slowdown or speedup.
+const rollingWindow = 5 * time.Second
+
+// rateSample is one (time, bytesCopied) observation kept by runTTYProgress
+// to compute a rolling-window throughput.
+type rateSample struct {
+	t     time.Time
+	bytes int64
+}
+
+// rollingRate appends the sample (now, bytesCopied) to samples, drops any
+// samples older than window, and returns the updated slice along with the
+// bytes/sec rate measured from the oldest remaining sample to now. At
+// least one sample is always kept so there's always an oldest to measure
+// from.
+func rollingRate(samples []rateSample, now time.Time, bytesCopied int64, window time.Duration) ([]rateSample, float64) {
+	samples = append(samples, rateSample{now, bytesCopied})
+	cutoff := now.Add(-window)
+	for len(samples) > 1 && samples[0].t.Before(cutoff) {
+		samples = samples[1:]
+	}
+	oldest := samples[0]
+	var rate float64
+	if secs := now.Sub(oldest.t).Seconds(); secs > 0 {
+		rate = float64(bytesCopied-oldest.bytes) / secs
+	}
+	return samples, rate
+}
+
+// runTTYProgress renders a live multi-line status (worker slots busy,
+// rolling throughput, ETA) until done is closed, using ANSI cursor moves
+// to repaint in place instead of scrolling the terminal. Throughput is
+// averaged over the trailing rollingWindow rather than the latest 250ms
+// tick, so a single bursty or stalled tick doesn't swing the ETA.
+func runTTYProgress(cfg *config, done <-chan struct{}) {
+	const lines = 3
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+	var samples []rateSample
+	printed := false
+	render := func() {
+		bytesCopied, filesCompleted, filesInFlight, totalBytes, totalFiles := cfg.progress.snapshot()
+		var rate float64
+		samples, rate = rollingRate(samples, time.Now(), bytesCopied, rollingWindow)
+		var eta time.Duration
+		if rate > 0 && totalBytes > bytesCopied {
+			eta = time.Duration(float64(totalBytes-bytesCopied) / rate * float64(time.Second))
+		}
+		if printed {
+			fmt.Printf("\033[%dA", lines)
+		}
+		printed = true
+		fmt.Printf("\033[2Kworkers busy: %d/%d\n", filesInFlight, cfg.parallelTasks)
+		fmt.Printf("\033[2Kthroughput:   %.1f MB/s\n", rate/(1<<20))
+		fmt.Printf("\033[2Kfiles:        %d/%d done, ETA %s\n", filesCompleted, totalFiles, eta.Truncate(time.Second))
+	}
+	for {
+		select {
+		case <-done:
+			render()
+			return
+		case <-ticker.C:
+			render()
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package cpcp
+
+import (
+	"testing"
+	"time"
+)
+
+// TestRollingRateSmoothsBurstyTick guards the rolling-window ETA: a single
+// bursty tick should be smoothed by the trailing window rather than
+// producing an instantaneous rate computed from just that tick.
+func TestRollingRateSmoothsBurstyTick(t *testing.T) {
+	start := time.Unix(0, 0)
+	var samples []rateSample
+
+	// Four quiet 250ms ticks at 1MB/s, then one bursty tick that alone
+	// would read as a much higher instantaneous rate.
+	var rate float64
+	bytesCopied := int64(0)
+	for i := 1; i <= 4; i++ {
+		bytesCopied += 256 * 1024 // 1MB/s over a 250ms tick
+		samples, rate = rollingRate(samples, start.Add(time.Duration(i)*250*time.Millisecond), bytesCopied, rollingWindow)
+	}
+	bytesCopied += 4 * 1024 * 1024 // a 16MB/s burst on the 5th tick alone
+	samples, rate = rollingRate(samples, start.Add(5*250*time.Millisecond), bytesCopied, rollingWindow)
+
+	instantaneous := 16.0 * (1 << 20)
+	if rate >= instantaneous/2 {
+		t.Errorf("rate = %.0f B/s, want well under the bursty tick's instantaneous %.0f B/s", rate, instantaneous)
+	}
+
+	_ = samples
+}
+
+// TestRollingRateEvictsOldSamples guards the window eviction: once enough
+// time has passed, samples older than rollingWindow should no longer
+// contribute to the rate computation.
+func TestRollingRateEvictsOldSamples(t *testing.T) {
+	start := time.Unix(0, 0)
+	var samples []rateSample
+
+	samples, _ = rollingRate(samples, start, 0, rollingWindow)
+	// Take the next sample (1MiB copied) well beyond the window.
+	samples, rate := rollingRate(samples, start.Add(rollingWindow*3), 1<<20, rollingWindow)
+
+	if len(samples) != 1 {
+		t.Errorf("len(samples) = %d, want 1 (old samples should have been evicted)", len(samples))
+	}
+	// With the stale zero-byte sample evicted, the only remaining sample
+	// is the current one itself, so there's no elapsed time to divide by.
+	if rate != 0 {
+		t.Errorf("rate = %.0f, want 0 once the only remaining sample is the current one", rate)
+	}
+}
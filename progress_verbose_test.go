@@ -0,0 +1,61 @@
+package cpcp
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+// TestVerboseLogsRegularFileOnce guards against the -v plain-mode double
+// log: copier used to print "src -> dst" both before copying a regular
+// file and again via reportCopy afterward. Each copied file should appear
+// exactly once.
+func TestVerboseLogsRegularFileOnce(t *testing.T) {
+	root := t.TempDir()
+	src := path.Join(root, "src")
+	dst := path.Join(root, "dst")
+	if err := os.WriteFile(src, []byte("contents"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := captureStdout(t, func() {
+		if err := CPCP([]string{"--verbose", src, dst}); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	n := strings.Count(out, src+" -> "+dst)
+	if n != 1 {
+		t.Errorf("log line for %s appeared %d times, want 1 (output: %q)", src, n, out)
+	}
+}
+
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = saved }()
+
+	done := make(chan string)
+	go func() {
+		buf := make([]byte, 0, 4096)
+		for {
+			chunk := make([]byte, 4096)
+			n, err := r.Read(chunk)
+			buf = append(buf, chunk[:n]...)
+			if err != nil {
+				break
+			}
+		}
+		done <- string(buf)
+	}()
+
+	fn()
+	w.Close()
+	return <-done
+}